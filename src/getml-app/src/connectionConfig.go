@@ -0,0 +1,136 @@
+// Copyright 2022 The SQLNet Company GmbH
+//
+// This file is licensed under the Elastic License 2.0 (ELv2).
+// Refer to the LICENSE.txt file in the root of the repository
+// for details.
+//
+
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"flag"
+	"fmt"
+	"os"
+)
+
+// ConnectionConfig controls how the launcher talks to the local engine.
+// With TLSEnabled false it behaves exactly as before (a plain connection).
+// With TLSEnabled true, the connection is wrapped in TLS, and supplying
+// ClientCertFile/ClientKeyFile additionally presents a client certificate
+// (mutual TLS). TLS is layered on top of whichever Transport is selected.
+type ConnectionConfig struct {
+
+	// Transport selects tcp or unix. The zero value behaves as TransportTCP.
+	Transport Transport
+
+	// HomeDir, Version and InstallDirOverride are only used to resolve the
+	// Unix domain socket path for TransportUnix; see engineSocketPath.
+	HomeDir            string
+	Version            string
+	InstallDirOverride string
+
+	// TLSEnabled wraps the connection to the engine in TLS.
+	TLSEnabled bool
+
+	// CAFile is the path to a PEM-encoded CA bundle used to verify the
+	// engine's certificate. If empty, the system trust store is used.
+	CAFile string
+
+	// ClientCertFile and ClientKeyFile, if both set, are presented to the
+	// engine as a client certificate (mTLS).
+	ClientCertFile string
+	ClientKeyFile  string
+
+	// ServerName overrides the name used for certificate verification and
+	// SNI. If empty, "localhost" is used, matching the address we dial.
+	ServerName string
+
+	// MinVersion is the minimum TLS version to accept, e.g. tls.VersionTLS12.
+	// Zero means the crypto/tls default.
+	MinVersion uint16
+
+	// Insecure disables certificate verification entirely. It exists for
+	// local development only and must never be set in production.
+	Insecure bool
+}
+
+// RegisterConnectionConfigFlags registers CLI flags on fs for the TLS
+// fields of ConnectionConfig other than MinVersion, which has no flag.
+// Transport and InstallDirOverride have their own flags (see
+// RegisterTransportFlags and RegisterInstallDirFlag); HomeDir and Version
+// have none. It returns a ConnectionConfig that is only valid for reading
+// after fs.Parse has been called.
+func RegisterConnectionConfigFlags(fs *flag.FlagSet) *ConnectionConfig {
+
+	config := &ConnectionConfig{}
+
+	fs.BoolVar(&config.TLSEnabled, "engine-tls", false,
+		"wrap the connection to the engine in TLS")
+
+	fs.StringVar(&config.CAFile, "engine-tls-ca", "",
+		"path to a PEM-encoded CA bundle used to verify the engine's certificate")
+
+	fs.StringVar(&config.ClientCertFile, "engine-tls-cert", "",
+		"path to a PEM-encoded client certificate for mutual TLS")
+
+	fs.StringVar(&config.ClientKeyFile, "engine-tls-key", "",
+		"path to the PEM-encoded private key matching -engine-tls-cert")
+
+	fs.StringVar(&config.ServerName, "engine-tls-server-name", "",
+		"server name to use for TLS verification and SNI, defaults to localhost")
+
+	fs.BoolVar(&config.Insecure, "engine-tls-insecure", false,
+		"disable TLS certificate verification (development only)")
+
+	return config
+}
+
+// tlsConfig builds a *tls.Config from config, or returns nil if TLS is not
+// enabled. It is evaluated fresh for every dial attempt so that certificate
+// files edited between retries are picked up.
+func (config *ConnectionConfig) tlsConfig() (*tls.Config, error) {
+
+	if !config.TLSEnabled {
+		return nil, nil
+	}
+
+	serverName := config.ServerName
+	if serverName == "" {
+		serverName = "localhost"
+	}
+
+	tlsConf := &tls.Config{
+		ServerName:         serverName,
+		MinVersion:         config.MinVersion,
+		InsecureSkipVerify: config.Insecure,
+	}
+
+	if config.CAFile != "" {
+
+		pemBytes, err := os.ReadFile(config.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read engine CA bundle %q: %w", config.CAFile, err)
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pemBytes) {
+			return nil, fmt.Errorf("no certificates found in engine CA bundle %q", config.CAFile)
+		}
+
+		tlsConf.RootCAs = pool
+	}
+
+	if config.ClientCertFile != "" && config.ClientKeyFile != "" {
+
+		cert, err := tls.LoadX509KeyPair(config.ClientCertFile, config.ClientKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load engine client certificate: %w", err)
+		}
+
+		tlsConf.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConf, nil
+}
@@ -0,0 +1,55 @@
+// Copyright 2022 The SQLNet Company GmbH
+//
+// This file is licensed under the Elastic License 2.0 (ELv2).
+// Refer to the LICENSE.txt file in the root of the repository
+// for details.
+//
+
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// EngineUnreachableError is returned by createConnectionToEngine when every
+// attempt allowed by the RetryPolicy was exhausted without a successful
+// connection. It is distinct from *DialDeadlineExceededError, so callers
+// can tell "we gave up" apart from "we ran out of time".
+type EngineUnreachableError struct {
+	Attempts int
+	LastErr  error
+}
+
+func (err *EngineUnreachableError) Error() string {
+	return fmt.Sprintf("engine never became reachable after %d attempt(s): %v", err.Attempts, err.LastErr)
+}
+
+func (err *EngineUnreachableError) Unwrap() error {
+	return err.LastErr
+}
+
+// DialDeadlineExceededError is returned by createConnectionToEngine when the
+// RetryPolicy's OverallDeadline elapsed before a connection succeeded.
+type DialDeadlineExceededError struct {
+	Elapsed time.Duration
+	LastErr error
+}
+
+func (err *DialDeadlineExceededError) Error() string {
+	return fmt.Sprintf("timed out connecting to the engine after %s: %v", err.Elapsed, err.LastErr)
+}
+
+func (err *DialDeadlineExceededError) Unwrap() error {
+	return err.LastErr
+}
+
+// UnknownTransportError is returned when a ConnectionConfig names a
+// Transport that createConnectionToEngine does not know how to dial.
+type UnknownTransportError struct {
+	Transport Transport
+}
+
+func (err *UnknownTransportError) Error() string {
+	return fmt.Sprintf("unknown engine transport %q", string(err.Transport))
+}
@@ -1,40 +1,133 @@
 // Copyright 2022 The SQLNet Company GmbH
-// 
-// This file is licensed under the Elastic License 2.0 (ELv2). 
-// Refer to the LICENSE.txt file in the root of the repository 
+//
+// This file is licensed under the Elastic License 2.0 (ELv2).
+// Refer to the LICENSE.txt file in the root of the repository
 // for details.
-// 
+//
 
 package main
 
 import (
+	"context"
+	"crypto/tls"
 	"net"
-	"strconv"
 	"time"
 )
 
-func createConnectionToEngine(tcpPort int) (*net.TCPConn, error) {
+// createConnectionToEngine connects to the local engine, retrying according
+// to policy using decorrelated-jitter exponential backoff. connConfig.
+// Transport selects whether tcpPort (tcp4) or a Unix domain socket is
+// dialed; the retry/backoff logic below is shared across both. If
+// connConfig enables TLS, the handshake is performed within the retry loop
+// so that handshake failures are retried exactly like connection refused.
+// Every dial and handshake is itself bounded by the time remaining until
+// policy.OverallDeadline, so an engine that accepts the connection but
+// hangs mid-handshake cannot block past the deadline.
+// It returns an *EngineUnreachableError if policy.MaxAttempts was
+// exhausted, or a *DialDeadlineExceededError if policy.OverallDeadline
+// elapsed first.
+func createConnectionToEngine(tcpPort int, policy RetryPolicy, connConfig ConnectionConfig) (net.Conn, error) {
 
-	tcpAddr, err := net.ResolveTCPAddr("tcp4", "localhost:"+strconv.Itoa(tcpPort))
+	dial, err := resolveDialer(tcpPort, connConfig)
 
 	if err != nil {
 		return nil, err
 	}
 
-	for i := 0; i < 5; i++ {
+	deadline := time.Time{}
 
-		conn, err := net.DialTCP("tcp", nil, tcpAddr)
+	if policy.OverallDeadline > 0 {
+		deadline = time.Now().Add(policy.OverallDeadline)
+	}
+
+	var lastErr error
+	var sleep time.Duration
+
+	for attempt := 0; attempt < policy.MaxAttempts; attempt++ {
+
+		tlsConf, tlsErr := connConfig.tlsConfig()
+
+		if tlsErr != nil {
+			return nil, tlsErr
+		}
+
+		attemptCtx, cancel := attemptContext(deadline)
+
+		conn, dialErr := dialOnce(attemptCtx, dial, tlsConf)
 
-		if err == nil {
+		cancel()
+
+		if dialErr == nil {
 			return conn, nil
-		} else if i == 4 {
-			return nil, err
 		}
 
-		time.Sleep(time.Second)
+		lastErr = dialErr
+
+		if !deadline.IsZero() && !time.Now().Before(deadline) {
+			return nil, &DialDeadlineExceededError{Elapsed: policy.OverallDeadline, LastErr: lastErr}
+		}
+
+		if attempt == policy.MaxAttempts-1 {
+			break
+		}
+
+		sleep = policy.nextSleep(sleep)
+
+		if !deadline.IsZero() {
+			remaining := time.Until(deadline)
+			if remaining <= 0 {
+				return nil, &DialDeadlineExceededError{Elapsed: policy.OverallDeadline, LastErr: lastErr}
+			}
+			if sleep > remaining {
+				sleep = remaining
+			}
+		}
+
+		time.Sleep(sleep)
+	}
+
+	return nil, &EngineUnreachableError{Attempts: policy.MaxAttempts, LastErr: lastErr}
 
+}
+
+// attemptContext returns a context bounded by the time remaining until
+// deadline, or context.Background() if deadline is the zero value (no
+// OverallDeadline configured). The returned cancel func must always be
+// called once the attempt finishes, successfully or not.
+func attemptContext(deadline time.Time) (context.Context, context.CancelFunc) {
+
+	if deadline.IsZero() {
+		return context.WithCancel(context.Background())
+	}
+
+	return context.WithDeadline(context.Background(), deadline)
+
+}
+
+// dialOnce performs a single connection attempt using dial, wrapping the
+// result in a TLS handshake when tlsConf is non-nil. dial is transport-
+// agnostic, so the same retry loop in createConnectionToEngine serves both
+// TCP and Unix domain socket connections. Both the dial and the handshake
+// are bounded by ctx, so a hung engine cannot stall an attempt forever.
+func dialOnce(ctx context.Context, dial func(context.Context) (net.Conn, error), tlsConf *tls.Config) (net.Conn, error) {
+
+	conn, err := dial(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if tlsConf == nil {
+		return conn, nil
+	}
+
+	tlsConn := tls.Client(conn, tlsConf)
+
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		conn.Close()
+		return nil, err
 	}
 
-	return nil, err
+	return tlsConn, nil
 
 }
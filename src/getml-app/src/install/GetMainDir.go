@@ -1,25 +1,72 @@
 // Copyright 2022 The SQLNet Company GmbH
-// 
-// This file is licensed under the Elastic License 2.0 (ELv2). 
-// Refer to the LICENSE.txt file in the root of the repository 
+//
+// This file is licensed under the Elastic License 2.0 (ELv2).
+// Refer to the LICENSE.txt file in the root of the repository
 // for details.
-// 
+//
 
 package install
 
 import (
+	"os"
 	"path/filepath"
 	"runtime"
 )
 
-// GetMainDir returns the main directory
-// to which we are copying our files.
-func GetMainDir(homeDir string, version string) string {
+// GetMainDir returns the main directory to which we are copying our files.
+// If installDirOverride is non-empty, it is honored verbatim on every
+// platform and no further resolution takes place. On Windows, the
+// returned directory is also created if it does not already exist; a
+// failure to do so is returned rather than silently producing a path that
+// does not exist.
+func GetMainDir(homeDir string, version string, installDirOverride string) (string, error) {
+
+	if installDirOverride != "" {
+		return filepath.Clean(installDirOverride), nil
+	}
 
 	if runtime.GOOS == "windows" {
-		return "."
+		return getWindowsMainDir(version)
+	}
+
+	return filepath.Join(GetHomeDir(homeDir), ".getML/"+version), nil
+
+}
+
+// getWindowsMainDir resolves the per-user install root on Windows, so that
+// versions stay isolated instead of being extracted next to the running
+// binary. It prefers %LOCALAPPDATA%, since that is where Windows expects
+// non-roaming per-machine application data to live, and falls back to
+// %APPDATA% and then os.UserConfigDir. On Windows os.UserConfigDir also
+// reads %APPDATA%, so when neither variable is set it fails and the
+// current directory "." is used as the root. The
+// directory is created with the process's default ACLs; tightening this
+// further to the inviting user only would require golang.org/x/sys/windows
+// and is left for a follow-up.
+func getWindowsMainDir(version string) (string, error) {
+
+	root := os.Getenv("LOCALAPPDATA")
+
+	if root == "" {
+		root = os.Getenv("APPDATA")
+	}
+
+	if root == "" {
+		if dir, err := os.UserConfigDir(); err == nil {
+			root = dir
+		}
+	}
+
+	if root == "" {
+		root = "."
+	}
+
+	mainDir := filepath.Clean(filepath.Join(root, "getML", version))
+
+	if err := os.MkdirAll(mainDir, os.ModePerm); err != nil {
+		return "", err
 	}
 
-	return filepath.Join(GetHomeDir(homeDir), ".getML/"+version)
+	return mainDir, nil
 
 }
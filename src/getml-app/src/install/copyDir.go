@@ -1,33 +1,293 @@
 // Copyright 2022 The SQLNet Company GmbH
-// 
-// This file is licensed under the Elastic License 2.0 (ELv2). 
-// Refer to the LICENSE.txt file in the root of the repository 
+//
+// This file is licensed under the Elastic License 2.0 (ELv2).
+// Refer to the LICENSE.txt file in the root of the repository
 // for details.
-// 
+//
 
 package install
 
 import (
-	"io/ioutil"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"runtime"
+	"sync"
+	"sync/atomic"
 )
 
-func copyDir(sourceDir string, mainDir string, overwrite bool) {
+// CopyOptions configures a copyDir run.
+type CopyOptions struct {
+
+	// Parallelism is the number of files copied concurrently. Zero means
+	// runtime.NumCPU().
+	Parallelism int
+
+	// Reporter, if non-nil, receives a ProgressEvent after every file is
+	// copied or skipped.
+	Reporter Reporter
+}
+
+// copyDir copies sourceDir into mainDir using a worker pool, and writes a
+// manifest.json recording the SHA-256 of every copied file. On a later run
+// over the same destination, files whose manifest entry still matches the
+// file on disk are skipped, making interrupted installs resumable. ctx is
+// checked throughout so a caller can abort an in-progress copy; if copyDir
+// fails or is cancelled, every file it copied during this call is removed
+// before returning, so a partially populated install does not look
+// complete.
+func copyDir(ctx context.Context, sourceDir string, mainDir string, overwrite bool, opts CopyOptions) error {
+
+	parallelism := opts.Parallelism
+	if parallelism <= 0 {
+		parallelism = runtime.NumCPU()
+	}
+
+	reporter := opts.Reporter
+	if reporter == nil {
+		reporter = NoopReporter{}
+	}
 
 	destinationDir := filepath.Join(mainDir, sourceDir)
 
-	os.MkdirAll(destinationDir, os.ModePerm)
+	if err := os.MkdirAll(destinationDir, os.ModePerm); err != nil {
+		return err
+	}
+
+	manifestPath := filepath.Join(destinationDir, "manifest.json")
+
+	oldManifest, err := loadManifest(manifestPath)
+	if err != nil {
+		return err
+	}
+
+	relPaths, totalBytes, err := collectFiles(sourceDir)
+	if err != nil {
+		return err
+	}
+
+	var (
+		mu           sync.Mutex
+		newManifest  = &Manifest{Entries: map[string]ManifestEntry{}}
+		copiedPaths  []string
+		bytesCopied  int64
+		firstErr     error
+		cancelSignal = make(chan struct{})
+		cancelOnce   sync.Once
+	)
+
+	fail := func(err error) {
+		cancelOnce.Do(func() {
+			mu.Lock()
+			firstErr = err
+			mu.Unlock()
+			close(cancelSignal)
+		})
+	}
+
+	tasks := make(chan string)
+
+	var wg sync.WaitGroup
+	for i := 0; i < parallelism; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for relPath := range tasks {
+
+				select {
+				case <-cancelSignal:
+					return
+				default:
+				}
+
+				if ctx.Err() != nil {
+					fail(ctx.Err())
+					return
+				}
+
+				entry, copied, err := copyOneFile(sourceDir, destinationDir, relPath, overwrite, oldManifest)
+				if err != nil {
+					fail(fmt.Errorf("copying %s: %w", relPath, err))
+					return
+				}
+
+				mu.Lock()
+				newManifest.Entries[relPath] = entry
+				if copied {
+					copiedPaths = append(copiedPaths, relPath)
+				}
+				mu.Unlock()
+
+				copiedSoFar := atomic.AddInt64(&bytesCopied, entry.Size)
+				reporter.Report(ProgressEvent{
+					CurrentFile: relPath,
+					BytesCopied: copiedSoFar,
+					TotalBytes:  totalBytes,
+				})
+			}
+		}()
+	}
+
+feed:
+	for _, relPath := range relPaths {
+		select {
+		case <-cancelSignal:
+			break feed
+		case tasks <- relPath:
+		}
+	}
+	close(tasks)
+
+	wg.Wait()
+
+	if firstErr != nil {
+		rollback(destinationDir, copiedPaths)
+		return firstErr
+	}
+
+	return newManifest.save(manifestPath)
+
+}
+
+// collectFiles walks sourceDir and returns the path of every entry that is
+// not a directory (symlinks included) relative to sourceDir, along with the
+// combined size of all of them.
+func collectFiles(sourceDir string) ([]string, int64, error) {
+
+	var relPaths []string
+	var totalBytes int64
+
+	err := filepath.WalkDir(sourceDir, func(path string, entry fs.DirEntry, err error) error {
+
+		if err != nil {
+			return err
+		}
+
+		if entry.IsDir() {
+			return nil
+		}
 
-	files, err := ioutil.ReadDir(sourceDir)
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
 
+		relPath, err := filepath.Rel(sourceDir, path)
+		if err != nil {
+			return err
+		}
+
+		relPaths = append(relPaths, relPath)
+		totalBytes += info.Size()
+
+		return nil
+
+	})
+
+	return relPaths, totalBytes, err
+
+}
+
+// copyOneFile copies a single file from sourceDir/relPath to
+// destinationDir/relPath, unless overwrite is false and a matching entry
+// for relPath already exists in oldManifest. It returns the ManifestEntry
+// describing the file on disk afterwards, and whether a copy actually took
+// place (as opposed to being skipped).
+func copyOneFile(sourceDir string, destinationDir string, relPath string, overwrite bool, oldManifest *Manifest) (ManifestEntry, bool, error) {
+
+	sourcePath := filepath.Join(sourceDir, relPath)
+	destPath := filepath.Join(destinationDir, relPath)
+
+	sourceInfo, err := os.Stat(sourcePath)
+	if err != nil {
+		return ManifestEntry{}, false, err
+	}
+
+	if !overwrite {
+		if old, ok := oldManifest.Entries[relPath]; ok {
+			if destInfo, err := os.Stat(destPath); err == nil && destInfo.Size() == old.Size && destInfo.Mode() == old.Mode {
+				if sum, err := sha256File(destPath); err == nil && sum == old.SHA256 {
+					return old, false, nil
+				}
+			}
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(destPath), os.ModePerm); err != nil {
+		return ManifestEntry{}, false, err
+	}
+
+	source, err := os.Open(sourcePath)
+	if err != nil {
+		return ManifestEntry{}, false, err
+	}
+	defer source.Close()
+
+	// os.OpenFile's perm argument is only applied when the file is created;
+	// if destPath survived from a previous run with a different mode (e.g.
+	// its executable bit got stripped), it needs an explicit os.Chmod.
+	dest, err := os.OpenFile(destPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, sourceInfo.Mode())
+	if err != nil {
+		return ManifestEntry{}, false, err
+	}
+	defer dest.Close()
+
+	hasher := sha256.New()
+
+	if _, err := io.Copy(dest, io.TeeReader(source, hasher)); err != nil {
+		dest.Close()
+		os.Remove(destPath)
+		return ManifestEntry{}, false, err
+	}
+
+	if err := os.Chmod(destPath, sourceInfo.Mode()); err != nil {
+		dest.Close()
+		os.Remove(destPath)
+		return ManifestEntry{}, false, err
+	}
+
+	entry := ManifestEntry{
+		RelPath: relPath,
+		Size:    sourceInfo.Size(),
+		SHA256:  hex.EncodeToString(hasher.Sum(nil)),
+		Mode:    sourceInfo.Mode(),
+	}
+
+	return entry, true, nil
+
+}
+
+// sha256File returns the hex-encoded SHA-256 checksum of the file at path.
+func sha256File(path string) (string, error) {
+
+	file, err := os.Open(path)
 	if err != nil {
-		panic(err)
+		return "", err
+	}
+	defer file.Close()
+
+	hasher := sha256.New()
+
+	if _, err := io.Copy(hasher, file); err != nil {
+		return "", err
 	}
 
-	for _, file := range files {
-		fname := filepath.Join(sourceDir, file.Name())
-		copyFile(fname, "", mainDir, overwrite)
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+
+}
+
+// rollback removes every file in copiedPaths from destinationDir, undoing
+// the files this call to copyDir actually wrote. Files that were skipped
+// because they already matched the manifest are left untouched.
+func rollback(destinationDir string, copiedPaths []string) {
+
+	for _, relPath := range copiedPaths {
+		os.Remove(filepath.Join(destinationDir, relPath))
 	}
 
 }
@@ -0,0 +1,58 @@
+// Copyright 2022 The SQLNet Company GmbH
+//
+// This file is licensed under the Elastic License 2.0 (ELv2).
+// Refer to the LICENSE.txt file in the root of the repository
+// for details.
+//
+
+package install
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestCopyOneFileRestoresMode guards against a regression where a
+// destination file whose permissions had drifted from the source (but
+// whose content still matched the manifest) was left with the wrong mode
+// forever on a resumed install.
+func TestCopyOneFileRestoresMode(t *testing.T) {
+
+	sourceDir := t.TempDir()
+	destDir := t.TempDir()
+
+	sourcePath := filepath.Join(sourceDir, "engine")
+	if err := os.WriteFile(sourcePath, []byte("binary"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	oldManifest := &Manifest{Entries: map[string]ManifestEntry{}}
+
+	entry, copied, err := copyOneFile(sourceDir, destDir, "engine", false, oldManifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !copied {
+		t.Fatal("expected first copy to report copied = true")
+	}
+
+	destPath := filepath.Join(destDir, "engine")
+	if err := os.Chmod(destPath, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	newManifest := &Manifest{Entries: map[string]ManifestEntry{"engine": entry}}
+
+	if _, _, err := copyOneFile(sourceDir, destDir, "engine", false, newManifest); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := os.Stat(destPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0755 {
+		t.Fatalf("expected mode 0755 to be restored, got %v", info.Mode().Perm())
+	}
+}
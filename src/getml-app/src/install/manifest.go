@@ -0,0 +1,70 @@
+// Copyright 2022 The SQLNet Company GmbH
+//
+// This file is licensed under the Elastic License 2.0 (ELv2).
+// Refer to the LICENSE.txt file in the root of the repository
+// for details.
+//
+
+package install
+
+import (
+	"encoding/json"
+	"os"
+)
+
+// ManifestEntry records everything copyDir needs to know to decide, on a
+// later run, whether a file can be skipped instead of copied again.
+type ManifestEntry struct {
+	RelPath string      `json:"relpath"`
+	Size    int64       `json:"size"`
+	SHA256  string      `json:"sha256"`
+	Mode    os.FileMode `json:"mode"`
+}
+
+// Manifest is the `manifest.json` written alongside a copied directory
+// tree, keyed by RelPath for quick lookup.
+type Manifest struct {
+	Entries map[string]ManifestEntry `json:"entries"`
+}
+
+// loadManifest reads the manifest at path. A missing file is not an error;
+// it simply yields an empty manifest, as is the case for a first-time
+// install.
+func loadManifest(path string) (*Manifest, error) {
+
+	data, err := os.ReadFile(path)
+
+	if os.IsNotExist(err) {
+		return &Manifest{Entries: map[string]ManifestEntry{}}, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	manifest := &Manifest{}
+
+	if err := json.Unmarshal(data, manifest); err != nil {
+		return nil, err
+	}
+
+	if manifest.Entries == nil {
+		manifest.Entries = map[string]ManifestEntry{}
+	}
+
+	return manifest, nil
+
+}
+
+// save writes manifest to path as indented JSON.
+func (manifest *Manifest) save(path string) error {
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, data, 0644)
+
+}
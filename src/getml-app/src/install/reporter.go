@@ -0,0 +1,31 @@
+// Copyright 2022 The SQLNet Company GmbH
+//
+// This file is licensed under the Elastic License 2.0 (ELv2).
+// Refer to the LICENSE.txt file in the root of the repository
+// for details.
+//
+
+package install
+
+// ProgressEvent describes the state of a copyDir run at the time a single
+// file finished copying (or was skipped because it already matched the
+// manifest).
+type ProgressEvent struct {
+	CurrentFile string
+	BytesCopied int64
+	TotalBytes  int64
+}
+
+// Reporter receives ProgressEvents from copyDir. Implementations must be
+// safe for concurrent use, since copyDir reports from multiple workers.
+type Reporter interface {
+	Report(event ProgressEvent)
+}
+
+// NoopReporter discards every event. It is the default when no Reporter is
+// supplied, so the CLI and GUI installer can share copyDir without the CLI
+// having to provide a no-op implementation itself.
+type NoopReporter struct{}
+
+// Report implements Reporter.
+func (NoopReporter) Report(ProgressEvent) {}
@@ -0,0 +1,26 @@
+// Copyright 2022 The SQLNet Company GmbH
+//
+// This file is licensed under the Elastic License 2.0 (ELv2).
+// Refer to the LICENSE.txt file in the root of the repository
+// for details.
+//
+
+package main
+
+import "flag"
+
+// RegisterInstallDirFlag registers the -install-dir flag on fs. It is
+// honored on every platform by install.GetMainDir and by
+// ConnectionConfig.InstallDirOverride (which uses it to locate the Unix
+// domain socket under the install directory). It returns a pointer that is
+// only valid for reading after fs.Parse has been called.
+func RegisterInstallDirFlag(fs *flag.FlagSet) *string {
+
+	installDir := ""
+
+	fs.StringVar(&installDir, "install-dir", "",
+		"override the directory getML is installed to/run from, instead of resolving it automatically")
+
+	return &installDir
+
+}
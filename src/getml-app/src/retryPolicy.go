@@ -0,0 +1,183 @@
+// Copyright 2022 The SQLNet Company GmbH
+//
+// This file is licensed under the Elastic License 2.0 (ELv2).
+// Refer to the LICENSE.txt file in the root of the repository
+// for details.
+//
+
+package main
+
+import (
+	"flag"
+	"math/rand"
+	"os"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy controls how createConnectionToEngine retries a dial
+// attempt that did not immediately succeed. Backoff is computed using
+// the "decorrelated jitter" strategy, which avoids the thundering-herd
+// effect of a fixed sleep while still converging on MaxBackoff.
+type RetryPolicy struct {
+
+	// MaxAttempts is the maximum number of dial attempts before giving up.
+	MaxAttempts int
+
+	// InitialBackoff is the lower bound used for every sleep, including the
+	// first one.
+	InitialBackoff time.Duration
+
+	// MaxBackoff is the upper bound a sleep duration can ever reach.
+	MaxBackoff time.Duration
+
+	// Multiplier is applied to the previous sleep duration to obtain the
+	// upper bound for the next one.
+	Multiplier float64
+
+	// JitterFraction controls how much of the window between
+	// InitialBackoff and the multiplier-scaled upper bound is drawn at
+	// random rather than always waited out in full. 1 (the default) is
+	// pure decorrelated jitter, uniformly sampling the whole window; 0
+	// disables randomness entirely, sleeping for the upper bound every
+	// time, i.e. plain exponential backoff. Values in between blend the
+	// two. Clamped to [0, 1].
+	JitterFraction float64
+
+	// OverallDeadline bounds the total time spent retrying, across all
+	// attempts. A zero value means no deadline.
+	OverallDeadline time.Duration
+}
+
+// DefaultRetryPolicy returns the policy that reproduces the previous,
+// hard-coded behavior (5 attempts, 1s apart), but expressed in terms of
+// the new backoff parameters.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:     5,
+		InitialBackoff:  time.Second,
+		MaxBackoff:      time.Second,
+		Multiplier:      1,
+		JitterFraction:  1,
+		OverallDeadline: 0,
+	}
+}
+
+// nextSleep computes the duration to sleep before the next dial attempt,
+// given the sleep duration used for the previous attempt (zero if this is
+// the first retry). It implements decorrelated jitter: with the default
+// JitterFraction of 1, the result is drawn uniformly from [InitialBackoff,
+// min(MaxBackoff, prevSleep*Multiplier)]. A smaller JitterFraction shrinks
+// the random window, biasing the result toward the upper bound; 0 removes
+// the randomness entirely.
+func (policy RetryPolicy) nextSleep(prevSleep time.Duration) time.Duration {
+
+	upperBound := time.Duration(float64(prevSleep) * policy.Multiplier)
+
+	if prevSleep == 0 || upperBound < policy.InitialBackoff {
+		upperBound = policy.InitialBackoff
+	}
+
+	if upperBound > policy.MaxBackoff {
+		upperBound = policy.MaxBackoff
+	}
+
+	spread := upperBound - policy.InitialBackoff
+
+	if spread <= 0 {
+		return policy.InitialBackoff
+	}
+
+	jitterFraction := policy.JitterFraction
+	if jitterFraction < 0 {
+		jitterFraction = 0
+	}
+	if jitterFraction > 1 {
+		jitterFraction = 1
+	}
+
+	window := time.Duration(float64(spread) * jitterFraction)
+	floor := upperBound - window
+
+	if window <= 0 {
+		return floor
+	}
+
+	return floor + time.Duration(rand.Int63n(int64(window)))
+}
+
+// RetryPolicyFromEnv builds a RetryPolicy from environment variables,
+// falling back to DefaultRetryPolicy for anything that is unset or
+// unparsable. This lets users on slow VMs or CI tune dialing behavior
+// without recompiling the launcher.
+func RetryPolicyFromEnv() RetryPolicy {
+
+	policy := DefaultRetryPolicy()
+
+	if v, ok := os.LookupEnv("GETML_ENGINE_MAX_ATTEMPTS"); ok {
+		if n, err := strconv.Atoi(v); err == nil {
+			policy.MaxAttempts = n
+		}
+	}
+
+	if v, ok := os.LookupEnv("GETML_ENGINE_INITIAL_BACKOFF"); ok {
+		if d, err := time.ParseDuration(v); err == nil {
+			policy.InitialBackoff = d
+		}
+	}
+
+	if v, ok := os.LookupEnv("GETML_ENGINE_MAX_BACKOFF"); ok {
+		if d, err := time.ParseDuration(v); err == nil {
+			policy.MaxBackoff = d
+		}
+	}
+
+	if v, ok := os.LookupEnv("GETML_ENGINE_BACKOFF_MULTIPLIER"); ok {
+		if f, err := strconv.ParseFloat(v, 64); err == nil {
+			policy.Multiplier = f
+		}
+	}
+
+	if v, ok := os.LookupEnv("GETML_ENGINE_JITTER_FRACTION"); ok {
+		if f, err := strconv.ParseFloat(v, 64); err == nil {
+			policy.JitterFraction = f
+		}
+	}
+
+	if v, ok := os.LookupEnv("GETML_ENGINE_DIAL_DEADLINE"); ok {
+		if d, err := time.ParseDuration(v); err == nil {
+			policy.OverallDeadline = d
+		}
+	}
+
+	return policy
+}
+
+// RegisterRetryPolicyFlags registers CLI flags for every RetryPolicy field
+// on fs, seeded from the environment (and ultimately DefaultRetryPolicy).
+// It returns a RetryPolicy that is only valid for reading after fs.Parse
+// has been called.
+func RegisterRetryPolicyFlags(fs *flag.FlagSet) *RetryPolicy {
+
+	policy := RetryPolicyFromEnv()
+
+	fs.IntVar(&policy.MaxAttempts, "engine-max-attempts", policy.MaxAttempts,
+		"maximum number of attempts to connect to the engine")
+
+	fs.DurationVar(&policy.InitialBackoff, "engine-initial-backoff", policy.InitialBackoff,
+		"minimum time to wait between connection attempts")
+
+	fs.DurationVar(&policy.MaxBackoff, "engine-max-backoff", policy.MaxBackoff,
+		"maximum time to wait between connection attempts")
+
+	fs.Float64Var(&policy.Multiplier, "engine-backoff-multiplier", policy.Multiplier,
+		"factor applied to the previous backoff to obtain the next one")
+
+	fs.Float64Var(&policy.JitterFraction, "engine-jitter-fraction", policy.JitterFraction,
+		"fraction of the backoff window used for jitter (0 to 1)")
+
+	fs.DurationVar(&policy.OverallDeadline, "engine-dial-deadline", policy.OverallDeadline,
+		"overall time budget for connecting to the engine, 0 for no deadline")
+
+	return &policy
+}
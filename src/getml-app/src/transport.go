@@ -0,0 +1,132 @@
+// Copyright 2022 The SQLNet Company GmbH
+//
+// This file is licensed under the Elastic License 2.0 (ELv2).
+// Refer to the LICENSE.txt file in the root of the repository
+// for details.
+//
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/cyclux/getml-community/src/getml-app/src/install"
+)
+
+// Transport selects how the launcher reaches the local engine.
+type Transport string
+
+const (
+	// TransportTCP dials "localhost:<port>" over tcp4. This is the
+	// historical behavior and remains the default.
+	TransportTCP Transport = "tcp"
+
+	// TransportUnix dials a Unix domain socket under
+	// GetMainDir(...)/run/engine.sock.
+	//
+	// This only covers the launcher side of the connection: it avoids
+	// exposing the engine on a TCP port, and the client refuses to use a
+	// socket file whose permissions would let another local user connect
+	// (see checkSocketPermissions). The engine process itself is a
+	// separate binary outside this repository; it must create
+	// engine.sock with mode 0600 for the security gap this was meant to
+	// close to actually be closed. That listener-side change is not part
+	// of this commit.
+	TransportUnix Transport = "unix"
+)
+
+// RegisterTransportFlags registers the -engine-transport flag on fs. It
+// returns a pointer that is only valid for reading after fs.Parse has been
+// called.
+func RegisterTransportFlags(fs *flag.FlagSet) *Transport {
+
+	transport := TransportTCP
+
+	fs.Func("engine-transport", "transport used to reach the engine: tcp or unix (default tcp)", func(value string) error {
+		transport = Transport(value)
+		return nil
+	})
+
+	return &transport
+
+}
+
+// engineSocketPath returns the well-known path of the engine's Unix domain
+// socket for the given home directory and version.
+func engineSocketPath(homeDir string, version string, installDirOverride string) (string, error) {
+
+	mainDir, err := install.GetMainDir(homeDir, version, installDirOverride)
+
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(mainDir, "run", "engine.sock"), nil
+
+}
+
+// resolveDialer builds the function used to establish the underlying,
+// not-yet-TLS-wrapped connection to the engine, based on connConfig's
+// Transport. The retry/backoff loop in createConnectionToEngine calls the
+// returned function once per attempt, passing a ctx that is bounded by the
+// time remaining until RetryPolicy.OverallDeadline, so both transports
+// share the exact same retry and timeout logic.
+func resolveDialer(tcpPort int, connConfig ConnectionConfig) (func(ctx context.Context) (net.Conn, error), error) {
+
+	switch connConfig.Transport {
+
+	case "", TransportTCP:
+
+		address := "localhost:" + strconv.Itoa(tcpPort)
+
+		return func(ctx context.Context) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "tcp4", address)
+		}, nil
+
+	case TransportUnix:
+
+		socketPath, err := engineSocketPath(connConfig.HomeDir, connConfig.Version, connConfig.InstallDirOverride)
+		if err != nil {
+			return nil, err
+		}
+
+		return func(ctx context.Context) (net.Conn, error) {
+			if err := checkSocketPermissions(socketPath); err != nil {
+				return nil, err
+			}
+			return (&net.Dialer{}).DialContext(ctx, "unix", socketPath)
+		}, nil
+
+	default:
+		return nil, &UnknownTransportError{Transport: connConfig.Transport}
+	}
+
+}
+
+// checkSocketPermissions refuses to dial a Unix domain socket whose mode
+// grants access to group or other, so a misconfigured or compromised
+// engine listener cannot be silently trusted. This is a client-side
+// defense only: it cannot stop another local user from connecting to a
+// socket it finds this way, it only stops this launcher from doing so
+// itself.
+func checkSocketPermissions(socketPath string) error {
+
+	info, err := os.Stat(socketPath)
+
+	if err != nil {
+		return err
+	}
+
+	if info.Mode().Perm()&0077 != 0 {
+		return fmt.Errorf("refusing to connect: engine socket %q is accessible to other users (mode %v, expected 0600)", socketPath, info.Mode().Perm())
+	}
+
+	return nil
+
+}